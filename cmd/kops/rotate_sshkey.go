@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/kops/cmd/kops/util"
+	"k8s.io/kops/pkg/commands"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	rotateSSHKeyLong = `Rotates the SSH key pair used to access a cluster's nodes.
+
+Rotation is a two-phase process, mirroring "kops rotate ca": run this
+command once to import the new key and record it as the cluster's primary
+key, then run it again with --complete once you've confirmed access with
+the new key, to delete the retired key. This command does not update
+launch templates or roll instance groups.`
+
+	rotateSSHKeyExample = `
+	# Start rotating the SSH key for a cluster.
+	kops rotate ssh-key --name k8s-cluster.example.com --public-key ~/.ssh/id_new.pub --yes
+
+	# Finish the rotation once the new key has been confirmed.
+	kops rotate ssh-key --name k8s-cluster.example.com --complete --yes
+	`
+)
+
+// RotateSSHKeyOptions holds the flags for `kops rotate ssh-key`.
+type RotateSSHKeyOptions struct {
+	ClusterName string
+	PublicKey   string
+	Complete    bool
+	Yes         bool
+}
+
+// NewCmdRotateSSHKey builds the `kops rotate ssh-key` command.
+func NewCmdRotateSSHKey(f *util.Factory, out io.Writer) *cobra.Command {
+	options := &RotateSSHKeyOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "ssh-key",
+		Short:   "Rotate the cluster's SSH key pair",
+		Long:    rotateSSHKeyLong,
+		Example: rotateSSHKeyExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.ClusterName = rootCommand.ClusterName(true)
+			return RunRotateSSHKey(context.Background(), f, out, options)
+		},
+	}
+
+	cmd.Flags().StringVar(&options.PublicKey, "public-key", options.PublicKey, "path to the new SSH public key to import (required unless --complete)")
+	cmd.Flags().BoolVar(&options.Complete, "complete", options.Complete, "finish an in-progress rotation by deleting the retired SSH key")
+	cmd.Flags().BoolVar(&options.Yes, "yes", options.Yes, "actually rotate the SSH key, rather than performing a dry run")
+
+	return cmd
+}
+
+// RunRotateSSHKey runs the next phase of an SSH key rotation for the named
+// cluster, per RotateSSHKeyOptions.
+func RunRotateSSHKey(ctx context.Context, f *util.Factory, out io.Writer, options *RotateSSHKeyOptions) error {
+	if !options.Complete && options.PublicKey == "" {
+		return fmt.Errorf("--public-key is required to start a rotation (or pass --complete to finish one)")
+	}
+
+	var newPublicKey []byte
+	if options.PublicKey != "" {
+		b, err := os.ReadFile(options.PublicKey)
+		if err != nil {
+			return fmt.Errorf("error reading public key %q: %v", options.PublicKey, err)
+		}
+		newPublicKey = b
+	}
+
+	if !options.Yes {
+		fmt.Fprintf(out, "Must specify --yes to rotate the SSH key for cluster %q\n", options.ClusterName)
+		return nil
+	}
+
+	clientset, err := f.KopsClient()
+	if err != nil {
+		return err
+	}
+	cluster, err := clientset.GetCluster(ctx, options.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	cloud, err := f.Cloud(cluster)
+	if err != nil {
+		return err
+	}
+	awsCloud, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return fmt.Errorf("`kops rotate ssh-key` is only supported on AWS clusters")
+	}
+
+	cmd := &commands.RotateSSHKeyCmd{
+		ClusterName:  options.ClusterName,
+		Cluster:      cluster,
+		Cloud:        awsCloud,
+		NewPublicKey: newPublicKey,
+		Complete:     options.Complete,
+		Out:          out,
+	}
+
+	phase, err := cmd.RunRotation()
+	if err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("SSH key rotation for cluster %q is now in phase %q", options.ClusterName, phase)
+
+	return nil
+}
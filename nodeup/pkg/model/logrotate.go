@@ -17,8 +17,10 @@ limitations under the License.
 package model
 
 import (
+	"strconv"
 	"strings"
 
+	"k8s.io/kops/pkg/apis/kops"
 	"k8s.io/kops/pkg/apis/kops/model"
 	"k8s.io/kops/pkg/systemd"
 	"k8s.io/kops/upup/pkg/fi"
@@ -47,23 +49,37 @@ func (b *LogrotateBuilder) Build(c *fi.ModelBuilderContext) error {
 		c.AddTask(&nodetasks.Package{Name: "logrotate"})
 	}
 
-	b.addLogRotate(c, "docker", "/var/log/docker.log", logRotateOptions{})
-	b.addLogRotate(c, "kube-addons", "/var/log/kube-addons.log", logRotateOptions{})
-	b.addLogRotate(c, "kube-apiserver", "/var/log/kube-apiserver.log", logRotateOptions{})
-	b.addLogRotate(c, "kube-controller-manager", "/var/log/kube-controller-manager.log", logRotateOptions{})
-	b.addLogRotate(c, "kube-proxy", "/var/log/kube-proxy.log", logRotateOptions{})
-	b.addLogRotate(c, "kube-scheduler", "/var/log/kube-scheduler.log", logRotateOptions{})
-	b.addLogRotate(c, "kubelet", "/var/log/kubelet.log", logRotateOptions{})
-	b.addLogRotate(c, "etcd", "/var/log/etcd.log", logRotateOptions{})
-	b.addLogRotate(c, "etcd-events", "/var/log/etcd-events.log", logRotateOptions{})
+	b.addLogRotate(c, "docker", "/var/log/docker.log", b.logRotateOptionsFor("docker"))
+	b.addLogRotate(c, "kube-addons", "/var/log/kube-addons.log", b.logRotateOptionsFor("kube-addons"))
+	b.addLogRotate(c, "kube-apiserver", "/var/log/kube-apiserver.log", b.logRotateOptionsFor("kube-apiserver"))
+	b.addLogRotate(c, "kube-controller-manager", "/var/log/kube-controller-manager.log", b.logRotateOptionsFor("kube-controller-manager"))
+	b.addLogRotate(c, "kube-proxy", "/var/log/kube-proxy.log", b.logRotateOptionsFor("kube-proxy"))
+	b.addLogRotate(c, "kube-scheduler", "/var/log/kube-scheduler.log", b.logRotateOptionsFor("kube-scheduler"))
+	b.addLogRotate(c, "kubelet", "/var/log/kubelet.log", b.logRotateOptionsFor("kubelet"))
+	b.addLogRotate(c, "etcd", "/var/log/etcd.log", b.logRotateOptionsFor("etcd"))
+	b.addLogRotate(c, "etcd-events", "/var/log/etcd-events.log", b.logRotateOptionsFor("etcd-events"))
 	if model.UseCiliumEtcd(b.Cluster) {
-		b.addLogRotate(c, "etcd-cilium", "/var/log/etcd-cilium.log", logRotateOptions{})
+		b.addLogRotate(c, "etcd-cilium", "/var/log/etcd-cilium.log", b.logRotateOptionsFor("etcd-cilium"))
+	}
+
+	for _, extra := range b.logrotateSpec().ExtraPaths {
+		options := b.logRotateOptionsFor(extra.Name)
+		if extra.Override != nil {
+			options = applyLogrotateOverride(options, *extra.Override)
+		}
+		b.addLogRotate(c, extra.Name, extra.Path, options)
 	}
 
 	if err := b.addLogrotateService(c); err != nil {
 		return err
 	}
 
+	if b.logrotateSpec().MetricsEnabled != nil && *b.logrotateSpec().MetricsEnabled {
+		if err := b.addLogrotateExporter(c); err != nil {
+			return err
+		}
+	}
+
 	// Add timer to run hourly.
 	{
 		unit := &systemd.Manifest{}
@@ -95,6 +111,15 @@ func (b *LogrotateBuilder) addLogrotateService(c *fi.ModelBuilderContext) error
 	manifest.Set("Unit", "Description", "Rotate and Compress System Logs")
 	manifest.Set("Service", "ExecStart", "/usr/sbin/logrotate /etc/logrotate.conf")
 
+	if spec := b.logrotateSpec(); spec.MetricsEnabled != nil && *spec.MetricsEnabled {
+		// ExecStopPost runs once the service exits, whether it succeeded or
+		// failed, unlike ExecStartPost (which only runs on success) - this is
+		// what lets the exporter observe and count failed rotations. The
+		// leading "-" means a failure of the exporter itself doesn't affect
+		// logrotate.service's own result.
+		manifest.Set("Service", "ExecStopPost", "-"+logrotateExporterPath)
+	}
+
 	service := &nodetasks.Service{
 		Name:       "logrotate.service",
 		Definition: s(manifest.Render()),
@@ -105,49 +130,294 @@ func (b *LogrotateBuilder) addLogrotateService(c *fi.ModelBuilderContext) error
 	return nil
 }
 
+// logrotateTextfileCollectorDir is the node_exporter textfile collector
+// directory. When the node_exporter addon is present on a node, it should be
+// passed `--collector.textfile.directory=` pointing here so that
+// logrotate.prom is picked up automatically.
+const logrotateTextfileCollectorDir = "/var/lib/node_exporter/textfile_collector"
+
+// logrotateExporterPath is where the exporter script is installed.
+const logrotateExporterPath = "/opt/kops/bin/logrotate-exporter"
+
+// addLogrotateExporter installs the script that addLogrotateService wires up
+// to run after every logrotate.service invocation (success or failure), plus
+// the directory node_exporter's textfile collector reads from. On Flatcar,
+// where addLogrotateService leaves the distribution's own logrotate.service
+// unchanged, the script is installed but nothing runs it.
+func (b *LogrotateBuilder) addLogrotateExporter(c *fi.ModelBuilderContext) error {
+	c.AddTask(&nodetasks.File{
+		Path: logrotateTextfileCollectorDir,
+		Type: nodetasks.FileType_Directory,
+		Mode: s("0755"),
+	})
+
+	c.AddTask(&nodetasks.File{
+		Path: logrotateExporterStateDir,
+		Type: nodetasks.FileType_Directory,
+		Mode: s("0755"),
+	})
+
+	c.AddTask(&nodetasks.File{
+		Path:     logrotateExporterPath,
+		Contents: fi.NewStringResource(logrotateExporterScript),
+		Type:     nodetasks.FileType_File,
+		Mode:     s("0755"),
+	})
+
+	return nil
+}
+
+// logrotateExporterStateDir persists rotation/error counters between runs,
+// since logrotate's own status file only ever tracks the most recent
+// rotation date per log, not a running total.
+const logrotateExporterStateDir = "/var/lib/logrotate-exporter"
+
+// logrotateExporterScript parses /var/lib/logrotate/status (one
+// "path" "last-rotated-date" pair per line), cross-checks logrotate.service's
+// own result, and atomically writes a node_exporter textfile collector file
+// with last_success_timestamp/rotations/bytes_freed/errors per component. It
+// intentionally avoids any dependency beyond bash, coreutils, awk and
+// systemctl, since it runs on every supported distribution.
+const logrotateExporterScript = `#!/bin/bash
+# Generated by kops - do not edit.
+#
+# Parses logrotate's status file and writes a node_exporter textfile
+# collector file describing the health of the last rotation run.
+set -o errexit
+set -o nounset
+set -o pipefail
+
+STATUS_FILE=/var/lib/logrotate/status
+STATE_FILE="` + logrotateExporterStateDir + `/state"
+OUT_DIR="` + logrotateTextfileCollectorDir + `"
+OUT_FILE="${OUT_DIR}/logrotate.prom"
+TMP_FILE="$(mktemp "${OUT_DIR}/.logrotate.prom.XXXXXX")"
+NEW_STATE_FILE="$(mktemp "` + logrotateExporterStateDir + `/.state.XXXXXX")"
+
+cleanup() {
+  rm -f "${TMP_FILE}" "${NEW_STATE_FILE}"
+}
+trap cleanup EXIT
+
+touch "${STATE_FILE}"
+
+# service_failed is 1 if logrotate.service's most recent run did not exit
+# cleanly. logrotate doesn't report per-file errors, so a failure is
+# attributed to every component in the status file for this run. When run
+# as ExecStopPost the unit is still deactivating, not yet "failed", so the
+# result comes from the SERVICE_RESULT that systemd passes in.
+service_failed=0
+if [[ -n "${SERVICE_RESULT:-}" ]]; then
+  if [[ "${SERVICE_RESULT}" != "success" ]]; then
+    service_failed=1
+  fi
+elif systemctl is-failed --quiet logrotate.service; then
+  service_failed=1
+fi
+
+{
+  echo "# HELP logrotate_last_success_timestamp_seconds Time logrotate last processed this log."
+  echo "# TYPE logrotate_last_success_timestamp_seconds gauge"
+  echo "# HELP logrotate_rotations_total Number of times this log has been rotated."
+  echo "# TYPE logrotate_rotations_total counter"
+  echo "# HELP logrotate_bytes_freed_bytes Size of the most recently rotated backup for this log."
+  echo "# TYPE logrotate_bytes_freed_bytes gauge"
+  echo "# HELP logrotate_errors_total Number of failed logrotate runs; each failed run is counted for every log in the status file."
+  echo "# TYPE logrotate_errors_total counter"
+
+  if [[ -f "${STATUS_FILE}" ]]; then
+    # Status lines look like: "/var/log/kubelet.log" 2021-1-1-0:0:0
+    tail -n +2 "${STATUS_FILE}" | while read -r path rotated_date; do
+      path="${path%\"}"
+      path="${path#\"}"
+      component="$(basename "${path}" .log)"
+
+      # logrotate's own date format ("Y-M-D-H:MM:SS") isn't something GNU
+      # date -d accepts directly; reassemble it into "Y-M-D H:MM:SS" first.
+      IFS='-' read -r year month day time_of_day <<< "${rotated_date}"
+      padded_date="$(printf '%04d-%02d-%02d %s' "${year}" "${month}" "${day}" "${time_of_day}")"
+      ts="$(date -d "${padded_date}" +%s 2>/dev/null || echo 0)"
+
+      prev_ts=0
+      prev_rotations=0
+      prev_errors=0
+      read -r prev_ts prev_rotations prev_errors < <(awk -v c="${component}" '$1 == c {print $2, $3, $4}' "${STATE_FILE}") || true
+      prev_ts="${prev_ts:-0}"
+      prev_rotations="${prev_rotations:-0}"
+      prev_errors="${prev_errors:-0}"
+
+      rotations="${prev_rotations}"
+      if [[ "${ts}" != "${prev_ts}" ]]; then
+        rotations=$((prev_rotations + 1))
+      fi
+
+      errors="${prev_errors}"
+      if [[ "${service_failed}" -eq 1 ]]; then
+        errors=$((prev_errors + 1))
+      fi
+
+      # The rotated backup's name depends on the component's logrotate
+      # options: plain numeric ("${path}.1[.gz]") or, with dateext (the
+      # default on Flatcar, which forces a "-%Y%m%d-%s" DateFormat), a
+      # dash-suffixed name with no dot at all. Rather than guess every
+      # possible DateFormat, glob for anything longer than "${path}" that
+      # starts with it (so the live log itself is never matched) and
+      # take whichever backup was modified most recently, since that's
+      # always the one the last rotation produced.
+      bytes_freed=0
+      latest_backup="$(ls -t "${path}"?* 2>/dev/null | head -n1 || true)"
+      if [[ -n "${latest_backup}" ]]; then
+        bytes_freed="$(stat -c %s "${latest_backup}" 2>/dev/null || echo 0)"
+      fi
+
+      echo "logrotate_last_success_timestamp_seconds{component=\"${component}\"} ${ts}"
+      echo "logrotate_rotations_total{component=\"${component}\"} ${rotations}"
+      echo "logrotate_bytes_freed_bytes{component=\"${component}\"} ${bytes_freed}"
+      echo "logrotate_errors_total{component=\"${component}\"} ${errors}"
+
+      echo "${component} ${ts} ${rotations} ${errors}" >>"${NEW_STATE_FILE}"
+    done
+  fi
+} >"${TMP_FILE}"
+
+mv "${NEW_STATE_FILE}" "${STATE_FILE}"
+mv "${TMP_FILE}" "${OUT_FILE}"
+chmod 0644 "${OUT_FILE}"
+`
+
 type logRotateOptions struct {
-	MaxSize    string
-	DateFormat string
+	Rotate       int32
+	MaxSize      string
+	Frequency    string
+	Compress     bool
+	CopyTruncate bool
+	DateExt      bool
+	DateFormat   string
 }
 
-func (b *LogrotateBuilder) addLogRotate(c *fi.ModelBuilderContext, name, path string, options logRotateOptions) {
-	if options.MaxSize == "" {
-		options.MaxSize = "100M"
+// logrotateSpec returns the cluster's LogrotateSpec, or an empty spec if the
+// operator hasn't configured one, so callers can read it unconditionally.
+func (b *LogrotateBuilder) logrotateSpec() *kops.LogrotateSpec {
+	if b.Cluster.Spec.Logrotate != nil {
+		return b.Cluster.Spec.Logrotate
+	}
+	return &kops.LogrotateSpec{}
+}
+
+// logRotateOptionsFor builds the effective logRotateOptions for a named
+// component, applying the cluster-wide defaults and then any per-component
+// override configured for that name.
+func (b *LogrotateBuilder) logRotateOptionsFor(name string) logRotateOptions {
+	spec := b.logrotateSpec()
+
+	options := logRotateOptions{
+		Rotate:       5,
+		MaxSize:      "100M",
+		Frequency:    "daily",
+		Compress:     false,
+		CopyTruncate: true,
+	}
+
+	if spec.Rotate != nil {
+		options.Rotate = *spec.Rotate
+	}
+	if spec.MaxSize != "" {
+		options.MaxSize = spec.MaxSize
+	}
+	if spec.Frequency != "" {
+		options.Frequency = spec.Frequency
+	}
+	if spec.Compress != nil {
+		options.Compress = *spec.Compress
+	}
+	if spec.CopyTruncate != nil {
+		options.CopyTruncate = *spec.CopyTruncate
+	}
+	if spec.DateExt != nil {
+		options.DateExt = *spec.DateExt
+	}
+
+	if override, ok := spec.PerComponent[name]; ok {
+		options = applyLogrotateOverride(options, override)
 	}
 
 	// Flatcar sets "dateext" options, and maxsize-based rotation will fail if
 	// the file has been previously rotated on the same calendar date.
 	if b.Distribution == distributions.DistributionFlatcar {
+		options.DateExt = true
 		options.DateFormat = "-%Y%m%d-%s"
 	}
 
+	return options
+}
+
+// applyLogrotateOverride layers a per-component or per-path override on top
+// of an already-resolved set of options.
+func applyLogrotateOverride(options logRotateOptions, override kops.LogrotateComponentOverride) logRotateOptions {
+	if override.Rotate != nil {
+		options.Rotate = *override.Rotate
+	}
+	if override.MaxSize != "" {
+		options.MaxSize = override.MaxSize
+	}
+	if override.Frequency != "" {
+		options.Frequency = override.Frequency
+	}
+	if override.Compress != nil {
+		options.Compress = *override.Compress
+	}
+	if override.CopyTruncate != nil {
+		options.CopyTruncate = *override.CopyTruncate
+	}
+	if override.DateExt != nil {
+		options.DateExt = *override.DateExt
+	}
+	return options
+}
+
+func (b *LogrotateBuilder) addLogRotate(c *fi.ModelBuilderContext, name, path string, options logRotateOptions) {
+	contents := renderLogrotateConfig(path, options)
+
+	c.AddTask(&nodetasks.File{
+		Path:     "/etc/logrotate.d/" + name,
+		Contents: fi.NewStringResource(contents),
+		Type:     nodetasks.FileType_File,
+		Mode:     s("0644"),
+	})
+}
+
+// renderLogrotateConfig renders the logrotate.d fragment for a single log
+// path given a fully-resolved set of options.
+func renderLogrotateConfig(path string, options logRotateOptions) string {
 	lines := []string{
 		path + "{",
-		"  rotate 5",
-		"  copytruncate",
+		"  rotate " + strconv.Itoa(int(options.Rotate)),
 		"  missingok",
 		"  notifempty",
-		"  delaycompress",
 		"  maxsize " + options.MaxSize,
 	}
 
+	if options.CopyTruncate {
+		lines = append(lines, "  copytruncate", "  delaycompress")
+	}
+	if options.Compress {
+		lines = append(lines, "  compress")
+	} else {
+		lines = append(lines, "  nocompress")
+	}
+	if options.DateExt {
+		lines = append(lines, "  dateext")
+	}
 	if options.DateFormat != "" {
 		lines = append(lines, "  dateformat "+options.DateFormat)
 	}
 
 	lines = append(
 		lines,
-		"  daily",
+		"  "+options.Frequency,
 		"  create 0644 root root",
 		"}",
 	)
 
-	contents := strings.Join(lines, "\n") + "\n"
-
-	c.AddTask(&nodetasks.File{
-		Path:     "/etc/logrotate.d/" + name,
-		Contents: fi.NewStringResource(contents),
-		Type:     nodetasks.FileType_File,
-		Mode:     s("0644"),
-	})
+	return strings.Join(lines, "\n") + "\n"
 }
@@ -0,0 +1,180 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/nodeup/nodetasks"
+)
+
+func TestApplyLogrotateOverridePrecedence(t *testing.T) {
+	defaults := logRotateOptions{
+		Rotate:       5,
+		MaxSize:      "100M",
+		Frequency:    "daily",
+		Compress:     false,
+		CopyTruncate: true,
+	}
+
+	rotate := int32(20)
+	compress := true
+	override := kops.LogrotateComponentOverride{
+		Rotate:   &rotate,
+		MaxSize:  "500M",
+		Compress: &compress,
+	}
+
+	got := applyLogrotateOverride(defaults, override)
+
+	if got.Rotate != 20 {
+		t.Errorf("Rotate = %d, want 20", got.Rotate)
+	}
+	if got.MaxSize != "500M" {
+		t.Errorf("MaxSize = %q, want %q", got.MaxSize, "500M")
+	}
+	if !got.Compress {
+		t.Errorf("Compress = false, want true")
+	}
+	// Frequency and CopyTruncate were not overridden, so they must survive.
+	if got.Frequency != "daily" {
+		t.Errorf("Frequency = %q, want %q (unset fields must be unaffected)", got.Frequency, "daily")
+	}
+	if !got.CopyTruncate {
+		t.Errorf("CopyTruncate = false, want true (unset fields must be unaffected)")
+	}
+}
+
+func TestRenderLogrotateConfig(t *testing.T) {
+	options := logRotateOptions{
+		Rotate:       5,
+		MaxSize:      "100M",
+		Frequency:    "daily",
+		Compress:     true,
+		CopyTruncate: true,
+	}
+
+	got := renderLogrotateConfig("/var/log/kubelet.log", options)
+
+	for _, want := range []string{
+		"/var/log/kubelet.log{",
+		"  rotate 5",
+		"  maxsize 100M",
+		"  copytruncate",
+		"  compress",
+		"  daily",
+		"}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered config missing %q; got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "nocompress") {
+		t.Errorf("rendered config should not set nocompress when Compress is true")
+	}
+}
+
+func TestLogrotateExporterScriptReassemblesStatusDate(t *testing.T) {
+	// logrotate's status file uses "Y-M-D-H:MM:SS" (e.g. 2021-1-1-0:0:0),
+	// which GNU date -d rejects outright. The script must split it apart and
+	// reassemble it into something date -d accepts before parsing.
+	for _, want := range []string{
+		"IFS='-' read -r year month day time_of_day <<< \"${rotated_date}\"",
+		"padded_date=\"$(printf '%04d-%02d-%02d %s' \"${year}\" \"${month}\" \"${day}\" \"${time_of_day}\")\"",
+		`date -d "${padded_date}"`,
+	} {
+		if !strings.Contains(logrotateExporterScript, want) {
+			t.Errorf("logrotateExporterScript missing %q", want)
+		}
+	}
+}
+
+func TestLogrotateExporterScriptEmitsAllMetrics(t *testing.T) {
+	for _, metric := range []string{
+		"logrotate_last_success_timestamp_seconds",
+		"logrotate_rotations_total",
+		"logrotate_bytes_freed_bytes",
+		"logrotate_errors_total",
+	} {
+		if !strings.Contains(logrotateExporterScript, "TYPE "+metric) {
+			t.Errorf("logrotateExporterScript missing TYPE declaration for %s", metric)
+		}
+	}
+}
+
+func TestLogrotateExporterGlobMatchesDateExtBackups(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	// Flatcar forces DateFormat "-%Y%m%d-%s", producing a dash-suffixed
+	// backup name with no dot at all; the glob must match that, not just
+	// the dotted "${path}.1"/"${path}.1.gz" numeric-rotation names. The live
+	// log, written after the backup and so newer, must not be matched.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kubelet.log")
+	backup := path + "-20240102-1700000000"
+	if err := os.WriteFile(backup, []byte("x"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.WriteFile(path, []byte("live"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	out, err := exec.Command("bash", "-c", `ls -t "$1"?* | head -n1`, "_", path).Output()
+	if err != nil {
+		t.Fatalf("glob command error = %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != backup {
+		t.Errorf("glob matched %q, want %q (dateext-rotated backup)", got, backup)
+	}
+}
+
+func TestAddLogrotateServiceTriggersExporterOnFailureToo(t *testing.T) {
+	// ExecStopPost runs whether logrotate.service succeeded or failed;
+	// OnSuccess (or ExecStartPost) would silently skip failed runs.
+	enabled := true
+	b := &LogrotateBuilder{NodeupModelContext: &NodeupModelContext{
+		Cluster: &kops.Cluster{Spec: kops.ClusterSpec{Logrotate: &kops.LogrotateSpec{MetricsEnabled: &enabled}}},
+	}}
+	c := &fi.ModelBuilderContext{Tasks: make(map[string]fi.Task)}
+	if err := b.addLogrotateService(c); err != nil {
+		t.Fatalf("addLogrotateService() error = %v", err)
+	}
+
+	var definition string
+	for _, task := range c.Tasks {
+		if service, ok := task.(*nodetasks.Service); ok && service.Name == "logrotate.service" {
+			definition = fi.ValueOf(service.Definition)
+		}
+	}
+	if !strings.Contains(definition, "ExecStopPost=-"+logrotateExporterPath) {
+		t.Errorf("logrotate.service does not run the exporter via ExecStopPost; got:\n%s", definition)
+	}
+	if strings.Contains(definition, "ExecStartPost") {
+		t.Errorf("logrotate.service must not run the exporter via ExecStartPost; got:\n%s", definition)
+	}
+	if strings.Contains(logrotateExporterScript, "OnSuccess") {
+		t.Errorf("logrotateExporterScript must not rely on OnSuccess semantics")
+	}
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/apis/kops/model"
+	"k8s.io/kops/pkg/systemd"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/nodeup/nodetasks"
+)
+
+// LogShipperBuilder installs and configures a Fluent Bit log forwarder that
+// tails the same component logs LogrotateBuilder rotates, plus journald, and
+// ships them off-node. It is only active when ClusterSpec.Logging.Enabled is
+// set.
+type LogShipperBuilder struct {
+	*NodeupModelContext
+}
+
+var _ fi.ModelBuilder = &LogShipperBuilder{}
+
+// logShipperPaths mirrors the built-in component log paths
+// LogrotateBuilder.Build configures; fluentBitConfig adds etcd-cilium and
+// LogrotateSpec.ExtraPaths the same way, so the shipper tails exactly what
+// gets rotated locally.
+var logShipperPaths = []struct {
+	Name string
+	Path string
+}{
+	{"docker", "/var/log/docker.log"},
+	{"kube-addons", "/var/log/kube-addons.log"},
+	{"kube-apiserver", "/var/log/kube-apiserver.log"},
+	{"kube-controller-manager", "/var/log/kube-controller-manager.log"},
+	{"kube-proxy", "/var/log/kube-proxy.log"},
+	{"kube-scheduler", "/var/log/kube-scheduler.log"},
+	{"kubelet", "/var/log/kubelet.log"},
+	{"etcd", "/var/log/etcd.log"},
+	{"etcd-events", "/var/log/etcd-events.log"},
+}
+
+// fluentBitSecretsEnvFile is where resolved kops secrets referenced by the
+// logging destination are written, in systemd EnvironmentFile format, so
+// that the values themselves never appear in fluent-bit.conf.
+const fluentBitSecretsEnvFile = "/etc/fluent-bit/secrets.env"
+
+// Build is responsible for configuring the log shipper, if enabled.
+func (b *LogShipperBuilder) Build(c *fi.ModelBuilderContext) error {
+	spec := b.Cluster.Spec.Logging
+	if spec == nil || spec.Enabled == nil || !*spec.Enabled {
+		return nil
+	}
+
+	c.AddTask(&nodetasks.Package{Name: "fluent-bit"})
+
+	contents, err := b.fluentBitConfig(spec)
+	if err != nil {
+		return fmt.Errorf("error building fluent-bit config: %v", err)
+	}
+
+	c.AddTask(&nodetasks.File{
+		Path:     "/etc/fluent-bit/fluent-bit.conf",
+		Contents: fi.NewStringResource(contents),
+		Type:     nodetasks.FileType_File,
+		Mode:     s("0644"),
+	})
+
+	if luaScript := samplingLuaScript(spec.PerComponent); luaScript != "" {
+		c.AddTask(&nodetasks.File{
+			Path:     fluentBitSamplingScript,
+			Contents: fi.NewStringResource(luaScript),
+			Type:     nodetasks.FileType_File,
+			Mode:     s("0644"),
+		})
+	}
+
+	secretNames := secretNamesFor(spec.Destination)
+	if len(secretNames) > 0 {
+		envFile, err := b.fluentBitSecretsEnvFile(secretNames)
+		if err != nil {
+			return fmt.Errorf("error resolving fluent-bit secrets: %v", err)
+		}
+		c.AddTask(&nodetasks.File{
+			Path:     fluentBitSecretsEnvFile,
+			Contents: fi.NewStringResource(envFile),
+			Type:     nodetasks.FileType_File,
+			Mode:     s("0600"),
+		})
+	}
+
+	unit := &systemd.Manifest{}
+	unit.Set("Unit", "Description", "Fluent Bit Log Shipper")
+	unit.Set("Unit", "After", "network.target")
+	if len(secretNames) > 0 {
+		unit.Set("Service", "EnvironmentFile", fluentBitSecretsEnvFile)
+	}
+	unit.Set("Service", "ExecStart", "/opt/fluent-bit/bin/fluent-bit -c /etc/fluent-bit/fluent-bit.conf")
+	unit.Set("Service", "Restart", "always")
+
+	service := &nodetasks.Service{
+		Name:       "fluent-bit.service",
+		Definition: s(unit.Render()),
+	}
+	service.InitDefaults()
+	c.AddTask(service)
+
+	return nil
+}
+
+// secretNamesFor returns the kops secret names a LoggingDestinationSpec
+// references, so callers can resolve exactly the secrets they need.
+func secretNamesFor(dest kops.LoggingDestinationSpec) []string {
+	var names []string
+	if dest.Loki != nil && dest.Loki.BearerTokenSecret != "" {
+		names = append(names, dest.Loki.BearerTokenSecret)
+	}
+	if dest.Elasticsearch != nil && dest.Elasticsearch.BearerTokenSecret != "" {
+		names = append(names, dest.Elasticsearch.BearerTokenSecret)
+	}
+	return names
+}
+
+// fluentBitSecretsEnvFile resolves each named kops secret through the node's
+// secret store and renders them as systemd EnvironmentFile lines, keyed by
+// the same variable names fluentBitOutput interpolates into the config.
+func (b *LogShipperBuilder) fluentBitSecretsEnvFile(secretNames []string) (string, error) {
+	var sb strings.Builder
+	for _, name := range secretNames {
+		secret, err := b.SecretStore.FindSecret(name)
+		if err != nil {
+			return "", fmt.Errorf("error reading secret %q: %v", name, err)
+		}
+		if secret == nil {
+			return "", fmt.Errorf("secret %q not found", name)
+		}
+		sb.WriteString(fmt.Sprintf("%s=%s\n", fluentBitSecretEnvVar(name), string(secret.Data)))
+	}
+	return sb.String(), nil
+}
+
+// fluentBitConfig renders a minimal Fluent Bit configuration that tails the
+// component logs and journald, tags each record with node identity, and
+// forwards to the configured destination. copytruncate-based rotation is
+// safe to pair with this: Fluent Bit tracks file offsets by inode in its
+// tail DB, so a rotated file (same inode, truncated) is picked up from
+// offset zero instead of being re-shipped from the (now truncated) start of
+// the original content.
+func (b *LogShipperBuilder) fluentBitConfig(spec *kops.LoggingSpec) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString("[SERVICE]\n")
+	sb.WriteString("    Flush        5\n")
+	sb.WriteString("    Daemon       off\n")
+	sb.WriteString("    DB           /var/lib/fluent-bit/tail.db\n\n")
+
+	selectAll := spec.SelectAll == nil || *spec.SelectAll
+	if selectAll {
+		paths := append([]struct{ Name, Path string }{}, logShipperPaths...)
+		if model.UseCiliumEtcd(b.Cluster) {
+			paths = append(paths, struct{ Name, Path string }{"etcd-cilium", "/var/log/etcd-cilium.log"})
+		}
+		if b.Cluster.Spec.Logrotate != nil {
+			for _, extra := range b.Cluster.Spec.Logrotate.ExtraPaths {
+				paths = append(paths, struct{ Name, Path string }{extra.Name, extra.Path})
+			}
+		}
+
+		for _, p := range paths {
+			if rule, ok := spec.PerComponent[p.Name]; ok && rule.Drop != nil && *rule.Drop {
+				continue
+			}
+			sb.WriteString("[INPUT]\n")
+			sb.WriteString("    Name   tail\n")
+			sb.WriteString(fmt.Sprintf("    Tag    component.%s\n", p.Name))
+			sb.WriteString(fmt.Sprintf("    Path   %s\n", p.Path))
+			sb.WriteString("    DB     /var/lib/fluent-bit/tail.db\n\n")
+		}
+
+		sb.WriteString("[INPUT]\n")
+		sb.WriteString("    Name    systemd\n")
+		sb.WriteString("    Tag     journald\n\n")
+	}
+
+	if samplingLuaScript(spec.PerComponent) != "" {
+		sb.WriteString("[FILTER]\n")
+		sb.WriteString("    Name    lua\n")
+		sb.WriteString("    Match   component.*\n")
+		sb.WriteString(fmt.Sprintf("    script  %s\n", fluentBitSamplingScript))
+		sb.WriteString("    call    sample\n\n")
+	}
+
+	// node_name and availability_zone are filled in from the environment
+	// rather than baked into the static config, since the same rendered
+	// file is shared by every node in the instance group.
+	sb.WriteString("[FILTER]\n")
+	sb.WriteString("    Name    record_modifier\n")
+	sb.WriteString("    Match   *\n")
+	sb.WriteString("    Record  node_name ${NODE_NAME}\n")
+	sb.WriteString("    Record  availability_zone ${AWS_AVAILABILITY_ZONE}\n")
+	if b.InstanceGroup != nil {
+		sb.WriteString(fmt.Sprintf("    Record  instance_group %s\n", b.InstanceGroup.ObjectMeta.Name))
+	}
+	sb.WriteString(fmt.Sprintf("    Record  cluster_name %s\n\n", b.Cluster.ObjectMeta.Name))
+
+	output, err := b.fluentBitOutput(spec.Destination)
+	if err != nil {
+		return "", err
+	}
+	sb.WriteString(output)
+
+	return sb.String(), nil
+}
+
+func (b *LogShipperBuilder) fluentBitOutput(dest kops.LoggingDestinationSpec) (string, error) {
+	switch {
+	case dest.Loki != nil:
+		var sb strings.Builder
+		sb.WriteString("[OUTPUT]\n")
+		sb.WriteString("    Name   loki\n")
+		sb.WriteString("    Match  *\n")
+		sb.WriteString(fmt.Sprintf("    Host   %s\n", dest.Loki.URL))
+		if dest.Loki.TLS == nil || *dest.Loki.TLS {
+			sb.WriteString("    tls    on\n")
+		}
+		if dest.Loki.BearerTokenSecret != "" {
+			sb.WriteString(fmt.Sprintf("    header Authorization Bearer ${%s}\n", fluentBitSecretEnvVar(dest.Loki.BearerTokenSecret)))
+		}
+		return sb.String(), nil
+
+	case dest.Elasticsearch != nil:
+		var sb strings.Builder
+		sb.WriteString("[OUTPUT]\n")
+		// Fluent Bit's "es" output plugin has no generic header directive -
+		// only HTTP_User/HTTP_Passwd (HTTP basic auth) and AWS SigV4 - so a
+		// bearer token can't be sent through it. The generic "http" output
+		// does support custom headers, so bulk ingest is routed through that
+		// instead, the same way the Loki output above sends its token.
+		sb.WriteString("    Name   http\n")
+		sb.WriteString("    Match  *\n")
+		sb.WriteString(fmt.Sprintf("    Host   %s\n", dest.Elasticsearch.Host))
+		sb.WriteString("    URI    /_bulk\n")
+		sb.WriteString("    Format json_lines\n")
+		if dest.Elasticsearch.TLS == nil || *dest.Elasticsearch.TLS {
+			sb.WriteString("    tls    on\n")
+		}
+		if dest.Elasticsearch.BearerTokenSecret != "" {
+			sb.WriteString(fmt.Sprintf("    header Authorization Bearer ${%s}\n", fluentBitSecretEnvVar(dest.Elasticsearch.BearerTokenSecret)))
+		}
+		return sb.String(), nil
+
+	case dest.CloudWatchLogs != nil:
+		var sb strings.Builder
+		sb.WriteString("[OUTPUT]\n")
+		sb.WriteString("    Name         cloudwatch_logs\n")
+		sb.WriteString("    Match        *\n")
+		sb.WriteString(fmt.Sprintf("    log_group_name  %s\n", dest.CloudWatchLogs.LogGroupName))
+		if dest.CloudWatchLogs.Region != "" {
+			sb.WriteString(fmt.Sprintf("    region          %s\n", dest.CloudWatchLogs.Region))
+		}
+		return sb.String(), nil
+	}
+
+	return "", fmt.Errorf("logging enabled but no destination configured")
+}
+
+// fluentBitSecretEnvVar derives the environment variable name nodeup exports
+// a kops secret under, so the rendered config never embeds the token itself.
+func fluentBitSecretEnvVar(secretName string) string {
+	return "FLUENTBIT_SECRET_" + strings.ToUpper(strings.ReplaceAll(secretName, "-", "_"))
+}
+
+// fluentBitSamplingScript is where the generated per-component sampling Lua
+// script is written, if any component sets SampleRate.
+const fluentBitSamplingScript = "/etc/fluent-bit/sampling.lua"
+
+// samplingLuaScript renders a Lua filter script that keeps 1 out of every
+// SampleRate records for each component that sets one, dropping the rest.
+// Fluent Bit has no built-in "keep 1-in-N records" filter, so sampling is
+// implemented as a small per-tag counter in Lua. Components that don't set
+// SampleRate (or set it to 1) are left alone. Returns "" if no component
+// configures sampling, so callers can skip wiring the filter in entirely.
+func samplingLuaScript(rules map[string]kops.LoggingComponentRule) string {
+	names := make([]string, 0, len(rules))
+	for name, rule := range rules {
+		if rule.SampleRate != nil && *rule.SampleRate > 1 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+
+	var rates strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&rates, "  [\"component.%s\"] = %d,\n", name, *rules[name].SampleRate)
+	}
+
+	return fmt.Sprintf(`-- Generated by kops - do not edit.
+local sample_rates = {
+%s}
+local counters = {}
+
+function sample(tag, timestamp, record)
+  local rate = sample_rates[tag]
+  if rate == nil then
+    return 0, timestamp, record
+  end
+
+  counters[tag] = (counters[tag] or 0) + 1
+  if counters[tag] %% rate == 0 then
+    return 0, timestamp, record
+  end
+  return -1, timestamp, record
+end
+`, rates.String())
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/kops/pkg/apis/kops"
+)
+
+func TestSamplingLuaScript(t *testing.T) {
+	rate := int32(10)
+	dropRate := int32(1)
+	rules := map[string]kops.LoggingComponentRule{
+		"kube-proxy": {SampleRate: &rate},
+		"etcd":       {SampleRate: &dropRate}, // rate of 1 means "keep everything", no entry expected
+	}
+
+	script := samplingLuaScript(rules)
+	if script == "" {
+		t.Fatalf("expected a non-empty script when a component sets SampleRate > 1")
+	}
+	if !strings.Contains(script, `["component.kube-proxy"] = 10,`) {
+		t.Errorf("script missing kube-proxy sample rate entry:\n%s", script)
+	}
+	if strings.Contains(script, "component.etcd") {
+		t.Errorf("script should not have an entry for a component with SampleRate of 1:\n%s", script)
+	}
+}
+
+func TestSamplingLuaScriptEmptyWhenUnconfigured(t *testing.T) {
+	if got := samplingLuaScript(nil); got != "" {
+		t.Errorf("samplingLuaScript(nil) = %q, want empty string", got)
+	}
+}
+
+func TestFluentBitOutputElasticsearchRoutesBearerAuthThroughHTTP(t *testing.T) {
+	// Fluent Bit's "es" output has no generic header directive, so it can't
+	// carry a bearer token; this must go out via the "http" output, which
+	// does support "header", instead.
+	b := &LogShipperBuilder{}
+	dest := kops.LoggingDestinationSpec{
+		Elasticsearch: &kops.ElasticsearchLoggingSpec{
+			Host:              "es.example.com:9200",
+			BearerTokenSecret: "es-token",
+		},
+	}
+
+	got, err := b.fluentBitOutput(dest)
+	if err != nil {
+		t.Fatalf("fluentBitOutput() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"    Name   http\n",
+		"    Host   es.example.com:9200\n",
+		"    URI    /_bulk\n",
+		"    Format json_lines\n",
+		"    tls    on\n",
+		"    header Authorization Bearer ${FLUENTBIT_SECRET_ES_TOKEN}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q; got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Name   es\n") {
+		t.Errorf("output should not use the \"es\" plugin, which can't send the bearer token:\n%s", got)
+	}
+}
+
+func TestSecretNamesFor(t *testing.T) {
+	dest := kops.LoggingDestinationSpec{
+		Loki: &kops.LokiLoggingSpec{
+			URL:               "https://loki.example.com/loki/api/v1/push",
+			BearerTokenSecret: "loki-token",
+		},
+	}
+
+	names := secretNamesFor(dest)
+	if len(names) != 1 || names[0] != "loki-token" {
+		t.Errorf("secretNamesFor() = %v, want [loki-token]", names)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Cluster represents a kops cluster.
+type Cluster struct {
+	metav1.TypeMeta
+	metav1.ObjectMeta
+
+	Spec ClusterSpec `json:"spec,omitempty"`
+}
+
+// ClusterSpec defines the configuration for a cluster.
+type ClusterSpec struct {
+	// ConfigBase is the path where the configuration for the cluster
+	// (including the state used by commands like `kops rotate ssh-key`) is
+	// stored.
+	ConfigBase string `json:"configBase,omitempty"`
+
+	// Logrotate configures local log rotation of component logs on every
+	// node. See LogrotateBuilder.
+	Logrotate *LogrotateSpec `json:"logrotate,omitempty"`
+
+	// Logging configures shipping of node and component logs off-node. See
+	// LogShipperBuilder.
+	Logging *LoggingSpec `json:"logging,omitempty"`
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+// LoggingSpec configures shipping of node and component logs off-node via a
+// structured shipper (Fluent Bit by default), as an alternative or
+// complement to local rotation via LogrotateSpec.
+type LoggingSpec struct {
+	// Enabled turns on the log shipper on every node. Defaults to false.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Destination configures where shipped logs are sent. Exactly one of
+	// Loki, Elasticsearch, or CloudWatchLogs should be set.
+	Destination LoggingDestinationSpec `json:"destination,omitempty"`
+
+	// SelectAll, if true, ships journald as well as every log path that
+	// LogrotateSpec would otherwise rotate locally. Defaults to true.
+	SelectAll *bool `json:"selectAll,omitempty"`
+
+	// PerComponent allows tuning sampling/drop rules per component, keyed by
+	// the same component names used by LogrotateSpec.PerComponent.
+	PerComponent map[string]LoggingComponentRule `json:"perComponent,omitempty"`
+}
+
+// LoggingDestinationSpec identifies the off-node log sink and how to
+// authenticate to it.
+type LoggingDestinationSpec struct {
+	// Loki configures shipping logs to a Grafana Loki endpoint.
+	Loki *LokiLoggingSpec `json:"loki,omitempty"`
+	// Elasticsearch configures shipping logs to an Elasticsearch endpoint.
+	Elasticsearch *ElasticsearchLoggingSpec `json:"elasticsearch,omitempty"`
+	// CloudWatchLogs configures shipping logs to AWS CloudWatch Logs.
+	CloudWatchLogs *CloudWatchLogsLoggingSpec `json:"cloudWatchLogs,omitempty"`
+}
+
+// LokiLoggingSpec configures the Loki push endpoint.
+type LokiLoggingSpec struct {
+	// URL is the Loki push API endpoint, e.g. "https://loki.example.com/loki/api/v1/push".
+	URL string `json:"url"`
+	// TLS enables TLS when talking to URL. Defaults to true.
+	TLS *bool `json:"tls,omitempty"`
+	// BearerTokenSecret names a kops secret holding the bearer token to
+	// authenticate with, as stored via `kops create secret`.
+	BearerTokenSecret string `json:"bearerTokenSecret,omitempty"`
+}
+
+// ElasticsearchLoggingSpec configures an Elasticsearch output.
+type ElasticsearchLoggingSpec struct {
+	// Host is the Elasticsearch host, e.g. "es.example.com:9200".
+	Host string `json:"host"`
+	// TLS enables TLS when talking to Host. Defaults to true.
+	TLS *bool `json:"tls,omitempty"`
+	// BearerTokenSecret names a kops secret holding the bearer token to
+	// authenticate with.
+	BearerTokenSecret string `json:"bearerTokenSecret,omitempty"`
+}
+
+// CloudWatchLogsLoggingSpec configures a CloudWatch Logs output.
+type CloudWatchLogsLoggingSpec struct {
+	// LogGroupName is the CloudWatch Logs log group to write to.
+	LogGroupName string `json:"logGroupName"`
+	// Region is the AWS region of LogGroupName. If empty, no region is set in
+	// the generated output configuration.
+	Region string `json:"region,omitempty"`
+}
+
+// LoggingComponentRule tunes how aggressively a component's logs are
+// shipped, to keep noisy components from drowning out the rest.
+type LoggingComponentRule struct {
+	// SampleRate ships roughly one in every SampleRate records; 1 ships
+	// everything. Defaults to 1.
+	SampleRate *int32 `json:"sampleRate,omitempty"`
+	// Drop, if true, excludes this component's logs entirely.
+	Drop *bool `json:"drop,omitempty"`
+}
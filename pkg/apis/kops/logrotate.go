@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+// LogrotateSpec configures the log rotation policy that nodeup applies to
+// well-known component logs, such as those of kube-apiserver and kubelet.
+type LogrotateSpec struct {
+	// Rotate is the number of rotated log files to keep before the oldest is
+	// discarded. Defaults to 5.
+	Rotate *int32 `json:"rotate,omitempty"`
+	// MaxSize is the logrotate "maxsize" value, e.g. "100M". Defaults to "100M".
+	MaxSize string `json:"maxSize,omitempty"`
+	// Frequency is the logrotate rotation frequency, one of "daily", "weekly",
+	// or "monthly". Defaults to "daily".
+	Frequency string `json:"frequency,omitempty"`
+	// Compress enables gzip compression of rotated log files.
+	Compress *bool `json:"compress,omitempty"`
+	// CopyTruncate enables copytruncate mode, which is required for log files
+	// that are held open by a long-running process. Defaults to true.
+	CopyTruncate *bool `json:"copyTruncate,omitempty"`
+	// DateExt appends a date extension to rotated files instead of a number.
+	DateExt *bool `json:"dateExt,omitempty"`
+
+	// PerComponent allows overriding the global defaults above for specific
+	// components, keyed by component name (e.g. "kube-apiserver", "kubelet",
+	// "etcd").
+	PerComponent map[string]LogrotateComponentOverride `json:"perComponent,omitempty"`
+
+	// ExtraPaths lets operators register additional log files, such as those
+	// written by custom sidecars or the CNI, so that they are rotated using
+	// the same policy without having to patch kops.
+	ExtraPaths []LogrotateExtraPath `json:"extraPaths,omitempty"`
+
+	// MetricsEnabled installs a logrotate-exporter that writes a
+	// node_exporter textfile collector file after each rotation, so rotation
+	// health (rotations, bytes freed, errors, last success) is observable.
+	// On Flatcar the exporter is installed but not run after rotations.
+	// Defaults to false.
+	MetricsEnabled *bool `json:"metricsEnabled,omitempty"`
+}
+
+// LogrotateComponentOverride overrides the cluster-wide LogrotateSpec defaults
+// for a single named component.
+type LogrotateComponentOverride struct {
+	// Rotate overrides LogrotateSpec.Rotate for this component.
+	Rotate *int32 `json:"rotate,omitempty"`
+	// MaxSize overrides LogrotateSpec.MaxSize for this component.
+	MaxSize string `json:"maxSize,omitempty"`
+	// Frequency overrides LogrotateSpec.Frequency for this component.
+	Frequency string `json:"frequency,omitempty"`
+	// Compress overrides LogrotateSpec.Compress for this component.
+	Compress *bool `json:"compress,omitempty"`
+	// CopyTruncate overrides LogrotateSpec.CopyTruncate for this component.
+	CopyTruncate *bool `json:"copyTruncate,omitempty"`
+	// DateExt overrides LogrotateSpec.DateExt for this component.
+	DateExt *bool `json:"dateExt,omitempty"`
+}
+
+// LogrotateExtraPath registers an additional, non-built-in log file that
+// should be rotated alongside the standard kops-managed component logs.
+type LogrotateExtraPath struct {
+	// Name identifies the logrotate.d fragment, e.g. "calico" or "my-sidecar".
+	Name string `json:"name"`
+	// Path is the absolute path to the log file on the node.
+	Path string `json:"path"`
+	// Override optionally customizes the rotation policy for this path.
+	Override *LogrotateComponentOverride `json:"override,omitempty"`
+}
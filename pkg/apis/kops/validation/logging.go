@@ -0,0 +1,69 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kops/pkg/apis/kops"
+)
+
+func validateLogging(spec *kops.LoggingSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec == nil || spec.Enabled == nil || !*spec.Enabled {
+		return allErrs
+	}
+
+	destPath := fldPath.Child("destination")
+	set := 0
+	if spec.Destination.Loki != nil {
+		set++
+		if spec.Destination.Loki.URL == "" {
+			allErrs = append(allErrs, field.Required(destPath.Child("loki", "url"), "url is required"))
+		}
+	}
+	if spec.Destination.Elasticsearch != nil {
+		set++
+		if spec.Destination.Elasticsearch.Host == "" {
+			allErrs = append(allErrs, field.Required(destPath.Child("elasticsearch", "host"), "host is required"))
+		}
+	}
+	if spec.Destination.CloudWatchLogs != nil {
+		set++
+		if spec.Destination.CloudWatchLogs.LogGroupName == "" {
+			allErrs = append(allErrs, field.Required(destPath.Child("cloudWatchLogs", "logGroupName"), "logGroupName is required"))
+		}
+	}
+
+	switch set {
+	case 0:
+		allErrs = append(allErrs, field.Required(destPath, "exactly one of loki, elasticsearch, or cloudWatchLogs is required when logging is enabled"))
+	case 1:
+		// ok
+	default:
+		allErrs = append(allErrs, field.Invalid(destPath, spec.Destination, "exactly one of loki, elasticsearch, or cloudWatchLogs may be set"))
+	}
+
+	for name, rule := range spec.PerComponent {
+		rulePath := fldPath.Child("perComponent").Key(name)
+		if rule.SampleRate != nil && *rule.SampleRate < 1 {
+			allErrs = append(allErrs, field.Invalid(rulePath.Child("sampleRate"), *rule.SampleRate, "sampleRate must be >= 1"))
+		}
+	}
+
+	return allErrs
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kops/pkg/apis/kops"
+)
+
+var logrotateFrequencies = map[string]bool{
+	"":        true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+}
+
+// validLogrotateName restricts logrotate.d fragment names and component keys
+// to characters that are safe to use as a filename.
+var validLogrotateName = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+func validateLogrotate(spec *kops.LogrotateSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec == nil {
+		return allErrs
+	}
+
+	if spec.Rotate != nil && *spec.Rotate < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("rotate"), *spec.Rotate, "rotate must be >= 0"))
+	}
+	if !logrotateFrequencies[spec.Frequency] {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("frequency"), spec.Frequency, []string{"daily", "weekly", "monthly"}))
+	}
+
+	for name, override := range spec.PerComponent {
+		overridePath := fldPath.Child("perComponent").Key(name)
+		if !validLogrotateName.MatchString(name) {
+			allErrs = append(allErrs, field.Invalid(overridePath, name, "component name must be a valid filename"))
+		}
+		allErrs = append(allErrs, validateLogrotateComponentOverride(&override, overridePath)...)
+	}
+
+	seenPaths := map[string]bool{}
+	for i, extra := range spec.ExtraPaths {
+		extraPath := fldPath.Child("extraPaths").Index(i)
+		if extra.Name == "" {
+			allErrs = append(allErrs, field.Required(extraPath.Child("name"), "name is required"))
+		} else if !validLogrotateName.MatchString(extra.Name) {
+			allErrs = append(allErrs, field.Invalid(extraPath.Child("name"), extra.Name, "name must be a valid filename"))
+		}
+		if extra.Path == "" {
+			allErrs = append(allErrs, field.Required(extraPath.Child("path"), "path is required"))
+		} else if seenPaths[extra.Path] {
+			allErrs = append(allErrs, field.Duplicate(extraPath.Child("path"), extra.Path))
+		}
+		seenPaths[extra.Path] = true
+		if extra.Override != nil {
+			allErrs = append(allErrs, validateLogrotateComponentOverride(extra.Override, extraPath.Child("override"))...)
+		}
+	}
+
+	return allErrs
+}
+
+func validateLogrotateComponentOverride(override *kops.LogrotateComponentOverride, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if override.Rotate != nil && *override.Rotate < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("rotate"), *override.Rotate, "rotate must be >= 0"))
+	}
+	if override.Frequency != "" && !logrotateFrequencies[override.Frequency] {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("frequency"), override.Frequency, []string{"daily", "weekly", "monthly"}))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/pki"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awstasks"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/util/pkg/vfs"
+)
+
+// SSHKeyRotationPhase identifies where a `kops rotate ssh-key` rotation is in
+// its two-phase lifecycle. The phases mirror `kops rotate ca`'s Init /
+// Promote / Complete state machine so operators only have one rotation
+// workflow to learn.
+type SSHKeyRotationPhase string
+
+const (
+	// SSHKeyRotationPhaseInit imports the new SSH key pair alongside the
+	// existing one, without changing what any instance group references yet.
+	SSHKeyRotationPhaseInit SSHKeyRotationPhase = "Init"
+	// SSHKeyRotationPhasePromote records the new key as primary and the old
+	// one as retired. It does not update launch templates or roll the
+	// cluster.
+	SSHKeyRotationPhasePromote SSHKeyRotationPhase = "Promote"
+	// SSHKeyRotationPhaseComplete retires and deletes the old SSH key now
+	// that every instance has been rolled onto the new one.
+	SSHKeyRotationPhaseComplete SSHKeyRotationPhase = "Complete"
+)
+
+// rotationStateFile is where sshKeyRotationState is persisted, relative to
+// the cluster's ConfigBase, so that a rotation in progress survives between
+// separate `kops rotate ssh-key` invocations (e.g. the one that starts it
+// and the later one with --complete that finishes it).
+const rotationStateFile = "ssh-key-rotation.yaml"
+
+// RotateSSHKeyCmd drives a cluster's SSH key pair through a rotation,
+// keeping both the old and new keys usable until every instance group has
+// rolled, so that operators never lose access mid-rotation.
+type RotateSSHKeyCmd struct {
+	ClusterName string
+	Cluster     *kops.Cluster
+	Cloud       awsup.AWSCloud
+
+	// NewPublicKey is the public key material to import in the Init phase.
+	// It is ignored in later phases, where the in-progress rotation's key is
+	// reused.
+	NewPublicKey []byte
+
+	// Complete finishes an in-progress rotation instead of starting or
+	// continuing one, deleting the retired key once confirmed.
+	Complete bool
+
+	Out io.Writer
+
+	// importKeypair and deleteKeypair default to the awstasks.SSHKey-backed
+	// implementations below; they are overridable purely so tests can drive
+	// the phase-chaining logic in runRotationToTarget without a real Cloud.
+	importKeypair func(cloud awsup.AWSCloud, key *awstasks.SSHKey) error
+	deleteKeypair func(cloud awsup.AWSCloud, name *string) error
+}
+
+// sshKeyRotationState is the subset of cluster state the command needs to
+// decide which phase a rotation is in. It is persisted to the cluster's
+// state store as YAML, and kept as an explicit struct so the phase
+// transitions are easy to unit test in isolation from the state store.
+type sshKeyRotationState struct {
+	Phase       SSHKeyRotationPhase `json:"phase"`
+	PrimaryName string              `json:"primaryName"`
+	PendingName string              `json:"pendingName,omitempty"`
+	RetiredName string              `json:"retiredName,omitempty"`
+}
+
+// RunRotation loads any in-progress rotation state for the cluster and
+// drives it forward, persisting (or, once the rotation is finished,
+// deleting) the resulting state. Without --complete, it runs Init and
+// Promote - importing the new key and recording it as primary, without
+// rolling instance groups - and stops there so the operator can confirm access with the new key. With
+// --complete, it runs (or resumes) through to Complete, deleting the
+// retired key. Run is kept separate so the individual phase transitions can
+// be unit tested without a real state store.
+func (c *RotateSSHKeyCmd) RunRotation() (SSHKeyRotationPhase, error) {
+	statePath, err := c.statePath()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := readSSHKeyRotationState(statePath)
+	if err != nil {
+		return "", err
+	}
+
+	// runRotationToTarget always returns the (possibly partially advanced)
+	// state alongside any error, so whatever phases did succeed before a
+	// later one failed are still persisted below rather than lost, which
+	// would otherwise make the next attempt redo already-completed work
+	// (e.g. re-importing a key that AWS already has under that name).
+	phase, state, runErr := c.runRotationToTarget(state)
+
+	if runErr == nil && phase == SSHKeyRotationPhaseComplete && state.RetiredName == "" {
+		if err := statePath.Remove(); err != nil {
+			return phase, fmt.Errorf("error removing completed SSH key rotation state: %v", err)
+		}
+		return phase, nil
+	}
+
+	if err := writeSSHKeyRotationState(statePath, state); err != nil {
+		if runErr != nil {
+			return phase, runErr
+		}
+		return phase, err
+	}
+
+	return phase, runErr
+}
+
+// runRotationToTarget drives state through successive calls to Run until it
+// reaches the furthest phase this invocation is meant to perform: Promote
+// (import the new key, record it as primary) without --complete, or Complete
+// (delete the retired key) with it. It contains no I/O of its own so the
+// chaining logic can be unit tested without a real state store.
+func (c *RotateSSHKeyCmd) runRotationToTarget(state *sshKeyRotationState) (SSHKeyRotationPhase, *sshKeyRotationState, error) {
+	if state == nil {
+		state = &sshKeyRotationState{Phase: SSHKeyRotationPhaseInit}
+	}
+
+	phase := state.Phase
+	if phase == "" {
+		phase = SSHKeyRotationPhaseInit
+	}
+
+	for {
+		if !c.Complete && phase == SSHKeyRotationPhaseComplete {
+			// Promote already finished; without --complete there is
+			// nothing more to do until the operator confirms the new key.
+			break
+		}
+
+		var err error
+		phase, err = c.Run(state)
+		if err != nil {
+			return phase, state, err
+		}
+		state.Phase = phase
+
+		if phase == SSHKeyRotationPhaseComplete {
+			if !c.Complete {
+				// Init and Promote just ran; stop before deleting anything.
+				break
+			}
+			if state.RetiredName == "" {
+				// runComplete ran and cleared RetiredName on success.
+				break
+			}
+		}
+	}
+
+	return phase, state, nil
+}
+
+func (c *RotateSSHKeyCmd) statePath() (vfs.Path, error) {
+	if c.Cluster.Spec.ConfigBase == "" {
+		return nil, fmt.Errorf("cluster %q has no ConfigBase set", c.ClusterName)
+	}
+	return vfs.Context.BuildVfsPath(c.Cluster.Spec.ConfigBase + "/" + rotationStateFile)
+}
+
+func readSSHKeyRotationState(path vfs.Path) (*sshKeyRotationState, error) {
+	data, err := path.ReadFile()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading SSH key rotation state: %v", err)
+	}
+
+	state := &sshKeyRotationState{}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing SSH key rotation state: %v", err)
+	}
+	return state, nil
+}
+
+func writeSSHKeyRotationState(path vfs.Path, state *sshKeyRotationState) error {
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error serializing SSH key rotation state: %v", err)
+	}
+	if err := path.WriteFile(data, nil); err != nil {
+		return fmt.Errorf("error writing SSH key rotation state: %v", err)
+	}
+	return nil
+}
+
+// Run executes the next applicable phase of the rotation and reports which
+// phase it performed.
+func (c *RotateSSHKeyCmd) Run(state *sshKeyRotationState) (SSHKeyRotationPhase, error) {
+	if state == nil {
+		state = &sshKeyRotationState{Phase: SSHKeyRotationPhaseInit}
+	}
+
+	switch state.Phase {
+	case SSHKeyRotationPhaseInit, "":
+		return SSHKeyRotationPhasePromote, c.runInit(state)
+	case SSHKeyRotationPhasePromote:
+		return SSHKeyRotationPhaseComplete, c.runPromote(state)
+	case SSHKeyRotationPhaseComplete:
+		return SSHKeyRotationPhaseComplete, c.runComplete(state)
+	default:
+		return state.Phase, fmt.Errorf("unknown SSH key rotation phase %q", state.Phase)
+	}
+}
+
+// runInit imports the new key pair into AWS under a deterministic,
+// fingerprint-derived name. The rotation works with two independently-named
+// EC2 key pairs rather than renaming one in place: the old key pair is left
+// completely untouched until runComplete deletes it, so instances can
+// authenticate with either key for as long as both exist.
+func (c *RotateSSHKeyCmd) runInit(state *sshKeyRotationState) error {
+	if len(c.NewPublicKey) == 0 {
+		return fmt.Errorf("a new public key is required to start an SSH key rotation")
+	}
+
+	fingerprint, err := pki.ComputeAWSKeyFingerprint(string(c.NewPublicKey))
+	if err != nil {
+		return fmt.Errorf("error computing fingerprint for new SSH public key: %v", err)
+	}
+	pendingName := fmt.Sprintf("%s-%s", c.ClusterName, fingerprint[:8])
+
+	fmt.Fprintf(c.Out, "Importing new SSH key %q for cluster %q\n", pendingName, c.ClusterName)
+
+	newKey := &awstasks.SSHKey{
+		Name:      &pendingName,
+		Lifecycle: fi.LifecycleSync,
+		PublicKey: fi.NewBytesResource(c.NewPublicKey),
+	}
+	importKeypair := c.importKeypair
+	if importKeypair == nil {
+		importKeypair = func(cloud awsup.AWSCloud, key *awstasks.SSHKey) error {
+			return key.ImportKeypair(cloud)
+		}
+	}
+	if err := importKeypair(c.Cloud, newKey); err != nil {
+		return err
+	}
+
+	state.PendingName = pendingName
+	return nil
+}
+
+// runPromote records the pending key as the primary one and the previous
+// primary as retired. It does not update launch templates or roll instance
+// groups; only the rotation state changes.
+func (c *RotateSSHKeyCmd) runPromote(state *sshKeyRotationState) error {
+	if state.PendingName == "" {
+		return fmt.Errorf("no pending SSH key recorded for cluster %q; was Init run?", c.ClusterName)
+	}
+
+	fmt.Fprintf(c.Out, "Promoting SSH key %q to primary for cluster %q\n", state.PendingName, c.ClusterName)
+
+	state.RetiredName = state.PrimaryName
+	state.PrimaryName = state.PendingName
+	state.PendingName = ""
+	return nil
+}
+
+// runComplete deletes the retired SSH key now that no instance depends on
+// it any longer.
+func (c *RotateSSHKeyCmd) runComplete(state *sshKeyRotationState) error {
+	if state.RetiredName == "" {
+		return fmt.Errorf("no retired SSH key recorded for cluster %q", c.ClusterName)
+	}
+
+	fmt.Fprintf(c.Out, "Deleting retired SSH key %q from cluster %q\n", state.RetiredName, c.ClusterName)
+
+	retiredName := state.RetiredName
+	deleteKeypair := c.deleteKeypair
+	if deleteKeypair == nil {
+		deleteKeypair = (&awstasks.SSHKey{}).DeleteKeypair
+	}
+	if err := deleteKeypair(c.Cloud, &retiredName); err != nil {
+		return err
+	}
+
+	state.RetiredName = ""
+	return nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/kops/upup/pkg/fi/cloudup/awstasks"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+)
+
+func TestRotateSSHKeyCmdRunRequiresNewPublicKeyToStart(t *testing.T) {
+	cmd := &RotateSSHKeyCmd{ClusterName: "k8s-cluster.example.com", Out: &bytes.Buffer{}}
+
+	if _, err := cmd.Run(nil); err == nil {
+		t.Errorf("Run(nil) with no NewPublicKey = nil error, want an error")
+	}
+}
+
+func TestRotateSSHKeyCmdRunPromoteRequiresPending(t *testing.T) {
+	cmd := &RotateSSHKeyCmd{ClusterName: "k8s-cluster.example.com", Out: &bytes.Buffer{}}
+	state := &sshKeyRotationState{Phase: SSHKeyRotationPhasePromote, PrimaryName: "old-key"}
+
+	if _, err := cmd.Run(state); err == nil {
+		t.Errorf("Run() in Promote phase with no PendingName = nil error, want an error")
+	}
+}
+
+func TestRotateSSHKeyCmdRunPromoteAdvancesState(t *testing.T) {
+	cmd := &RotateSSHKeyCmd{ClusterName: "k8s-cluster.example.com", Out: &bytes.Buffer{}}
+	state := &sshKeyRotationState{
+		Phase:       SSHKeyRotationPhasePromote,
+		PrimaryName: "old-key",
+		PendingName: "new-key",
+	}
+
+	phase, err := cmd.Run(state)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if phase != SSHKeyRotationPhaseComplete {
+		t.Errorf("phase = %q, want %q", phase, SSHKeyRotationPhaseComplete)
+	}
+	if state.PrimaryName != "new-key" {
+		t.Errorf("PrimaryName = %q, want %q", state.PrimaryName, "new-key")
+	}
+	if state.RetiredName != "old-key" {
+		t.Errorf("RetiredName = %q, want %q", state.RetiredName, "old-key")
+	}
+	if state.PendingName != "" {
+		t.Errorf("PendingName = %q, want empty once promoted", state.PendingName)
+	}
+}
+
+func TestRotateSSHKeyCmdRunCompleteRequiresRetired(t *testing.T) {
+	cmd := &RotateSSHKeyCmd{ClusterName: "k8s-cluster.example.com", Out: &bytes.Buffer{}}
+	state := &sshKeyRotationState{Phase: SSHKeyRotationPhaseComplete}
+
+	if _, err := cmd.Run(state); err == nil {
+		t.Errorf("Run() in Complete phase with no RetiredName = nil error, want an error")
+	}
+}
+
+func TestRunRotationToTargetWithoutCompleteStopsAfterPromote(t *testing.T) {
+	cmd := &RotateSSHKeyCmd{
+		ClusterName: "k8s-cluster.example.com",
+		Out:         &bytes.Buffer{},
+	}
+	state := &sshKeyRotationState{
+		Phase:       SSHKeyRotationPhasePromote,
+		PrimaryName: "old-key",
+		PendingName: "new-key",
+	}
+
+	phase, got, err := cmd.runRotationToTarget(state)
+	if err != nil {
+		t.Fatalf("runRotationToTarget() error = %v", err)
+	}
+	if phase != SSHKeyRotationPhaseComplete {
+		t.Errorf("phase = %q, want %q", phase, SSHKeyRotationPhaseComplete)
+	}
+	// Promote must have actually run, even though --complete wasn't passed:
+	// the documented workflow is that the first call imports the key *and*
+	// promotes it to primary.
+	if got.RetiredName != "old-key" {
+		t.Errorf("RetiredName = %q, want %q (runPromote must run without --complete)", got.RetiredName, "old-key")
+	}
+	if got.PrimaryName != "new-key" {
+		t.Errorf("PrimaryName = %q, want %q", got.PrimaryName, "new-key")
+	}
+}
+
+func TestRunRotationToTargetPreservesProgressWhenALaterPhaseFails(t *testing.T) {
+	// Init and Promote both succeed (pending key imported, instance groups
+	// "rolled"), but Complete fails deleting the retired key. The returned
+	// state must still reflect the successful Init/Promote work rather than
+	// losing it, so a retry doesn't redo them (e.g. re-importing a key AWS
+	// already has under that deterministic name).
+	wantErr := fmt.Errorf("AWS is unavailable")
+	cmd := &RotateSSHKeyCmd{
+		ClusterName:  "k8s-cluster.example.com",
+		NewPublicKey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8g fake@example.com"),
+		Complete:     true,
+		Out:          &bytes.Buffer{},
+		importKeypair: func(cloud awsup.AWSCloud, key *awstasks.SSHKey) error {
+			return nil // pretend the import succeeded without touching cloud
+		},
+		deleteKeypair: func(cloud awsup.AWSCloud, name *string) error {
+			return wantErr
+		},
+	}
+
+	initial := &sshKeyRotationState{Phase: SSHKeyRotationPhaseInit, PrimaryName: "old-key"}
+	phase, got, err := cmd.runRotationToTarget(initial)
+	if err != wantErr {
+		t.Fatalf("runRotationToTarget() error = %v, want %v", err, wantErr)
+	}
+	if phase != SSHKeyRotationPhaseComplete {
+		t.Errorf("phase = %q, want %q (runComplete is the one that failed)", phase, SSHKeyRotationPhaseComplete)
+	}
+	if got.PrimaryName == "" || got.RetiredName == "" {
+		t.Errorf("got %+v, want Promote's progress (PrimaryName/RetiredName) preserved despite Complete failing", got)
+	}
+	if got.PendingName != "" {
+		t.Errorf("PendingName = %q, want empty: Promote already consumed it before Complete failed", got.PendingName)
+	}
+}
+
+func TestRunRotationToTargetCompleteResumesFromInitInsteadOfSkippingToComplete(t *testing.T) {
+	// A rotation that never got past Init must still be carried through
+	// Init and Promote when --complete is passed, instead of jumping
+	// straight to runComplete (which would wrongly blame a "no retired key"
+	// state that Promote was simply never given the chance to produce).
+	cmd := &RotateSSHKeyCmd{
+		ClusterName: "k8s-cluster.example.com",
+		Complete:    true,
+		Out:         &bytes.Buffer{},
+	}
+
+	_, _, err := cmd.runRotationToTarget(nil)
+	if err == nil {
+		t.Fatalf("runRotationToTarget() error = nil, want an error (no NewPublicKey set)")
+	}
+	if !strings.Contains(err.Error(), "new public key is required") {
+		t.Errorf("error = %q, want it to come from runInit (not a premature runComplete)", err.Error())
+	}
+}
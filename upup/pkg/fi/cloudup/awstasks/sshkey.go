@@ -151,7 +151,11 @@ func (s *SSHKey) CheckChanges(a, e, changes *SSHKey) error {
 	return nil
 }
 
-func (e *SSHKey) createKeypair(cloud awsup.AWSCloud) error {
+// ImportKeypair is exported so `kops rotate ssh-key` (pkg/commands) can
+// import a new key pair directly, independently of the declarative task
+// model: the rotation works with two separately-named EC2 key pairs rather
+// than renaming one task in place.
+func (e *SSHKey) ImportKeypair(cloud awsup.AWSCloud) error {
 	klog.V(2).Infof("Creating SSHKey with Name:%q", *e.Name)
 
 	request := &ec2.ImportKeyPairInput{
@@ -178,9 +182,24 @@ func (e *SSHKey) createKeypair(cloud awsup.AWSCloud) error {
 	return nil
 }
 
+// DeleteKeypair is exported for the same reason as ImportKeypair: it lets
+// `kops rotate ssh-key` delete the retired key pair by name directly, once
+// every instance has been rolled onto the new one.
+func (e *SSHKey) DeleteKeypair(cloud awsup.AWSCloud, name *string) error {
+	klog.V(2).Infof("Deleting retired SSHKey with Name:%q", *name)
+
+	request := &ec2.DeleteKeyPairInput{
+		KeyName: name,
+	}
+	if _, err := cloud.EC2().DeleteKeyPair(request); err != nil {
+		return fmt.Errorf("error deleting retired SSHKey %q: %v", *name, err)
+	}
+	return nil
+}
+
 func (_ *SSHKey) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *SSHKey) error {
 	if a == nil {
-		return e.createKeypair(t.Cloud)
+		return e.ImportKeypair(t.Cloud)
 	}
 
 	if !e.Shared {
@@ -200,6 +219,7 @@ func (_ *SSHKey) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *SS
 	if e.IsExistingKey() {
 		return nil
 	}
+
 	tfName := strings.Replace(*e.Name, ":", "", -1)
 	publicKey, err := t.AddFileResource("aws_key_pair", tfName, "public_key", e.PublicKey, false)
 	if err != nil {
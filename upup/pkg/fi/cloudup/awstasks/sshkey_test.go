@@ -0,0 +1,48 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package awstasks
+
+import (
+	"testing"
+
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+func TestSSHKeyCheckChangesRejectsRename(t *testing.T) {
+	// `kops rotate ssh-key` rotates by importing a new, independently-named
+	// EC2 key pair and later deleting the retired one - it never renames a
+	// key pair task in place, so CheckChanges must keep refusing that.
+	oldName := "old-key"
+	newName := "new-key"
+
+	a := &SSHKey{Name: &oldName}
+	e := &SSHKey{Name: &newName}
+	changes := &SSHKey{Name: &newName}
+
+	if err := (&SSHKey{}).CheckChanges(a, e, changes); err == nil {
+		t.Errorf("CheckChanges() = nil, want an error for a Name change")
+	}
+}
+
+func TestSSHKeyCheckChangesAllowsNameOnCreate(t *testing.T) {
+	e := &SSHKey{Name: fi.PtrTo("new-key")}
+	changes := &SSHKey{Name: fi.PtrTo("new-key")}
+
+	if err := (&SSHKey{}).CheckChanges(nil, e, changes); err != nil {
+		t.Errorf("CheckChanges() on create = %v, want nil", err)
+	}
+}